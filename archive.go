@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// archiveWorkers bounds how many conversations.history requests are
+// in flight at once, to stay well under Slack's rate limits.
+const archiveWorkers = 8
+
+var staleThreshold time.Duration
+
+var archiveStaleCmd = &cobra.Command{
+	Use:   "archiveStale --key=\"xxx\"",
+	Short: "archive customer support channels with no recent activity",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		client, _, err := checkAuth(ctx)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		matcher, err := newChannelMatcher(cfg)
+		if err != nil {
+			return err
+		}
+
+		channels, err := getAllChannels(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		var candidates []slack.Channel
+		for _, channel := range channels {
+			if !channel.IsMember || !strings.HasPrefix(channel.Name, "_") {
+				continue
+			}
+			if matcher.IsExcluded(channel.Name) {
+				continue
+			}
+			candidates = append(candidates, channel)
+		}
+
+		if len(candidates) == 0 {
+			fmt.Printf("\n--------------------\n")
+			fmt.Printf("There are no customer channels to consider archiving.\n")
+			return nil
+		}
+
+		decisions, err := evaluateStaleness(ctx, client, candidates, staleThreshold)
+		if err != nil {
+			return err
+		}
+
+		return applyArchiveDecisions(ctx, client, decisions)
+	}),
+}
+
+// archiveDecision records what archiveStaleCmd decided to do with a single
+// channel, and why.
+type archiveDecision struct {
+	channel slack.Channel
+	archive bool
+	reason  string
+}
+
+// evaluateStaleness fetches the latest message timestamp for each candidate
+// channel, using a bounded worker pool so as not to overrun Slack's rate
+// limits, and decides whether each one is stale enough to archive.
+func evaluateStaleness(
+	ctx context.Context, client *slack.Client, candidates []slack.Channel, stale time.Duration,
+) ([]archiveDecision, error) {
+	decisions := make([]archiveDecision, len(candidates))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, archiveWorkers)
+	for i, channel := range candidates {
+		i, channel := i, channel
+		group.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			decisions[i] = decideChannel(groupCtx, client, channel, stale)
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+func decideChannel(
+	ctx context.Context, client *slack.Client, channel slack.Channel, stale time.Duration,
+) archiveDecision {
+	if channel.IsExtShared {
+		return archiveDecision{channel: channel, archive: false, reason: "externally shared"}
+	}
+
+	history, err := client.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
+		ChannelID: channel.ID,
+		Limit:     1,
+	})
+	if err != nil {
+		return archiveDecision{channel: channel, archive: false, reason: fmt.Sprintf("history API error: %s", err.Error())}
+	}
+
+	if len(history.Messages) == 0 {
+		return archiveDecision{channel: channel, archive: true, reason: "no messages"}
+	}
+
+	lastTs, err := parseSlackTimestamp(history.Messages[0].Timestamp)
+	if err != nil {
+		return archiveDecision{channel: channel, archive: false, reason: fmt.Sprintf("unparseable timestamp: %s", err.Error())}
+	}
+
+	age := time.Since(lastTs)
+	if age < stale {
+		return archiveDecision{channel: channel, archive: false, reason: fmt.Sprintf("last message %s ago", age.Round(time.Hour))}
+	}
+
+	return archiveDecision{channel: channel, archive: true, reason: fmt.Sprintf("last message %s ago", age.Round(time.Hour))}
+}
+
+// parseSlackTimestamp parses a Slack message timestamp, which is a Unix
+// time in seconds with a fractional, dot-separated sequence suffix (e.g.
+// "1583334395.000400").
+func parseSlackTimestamp(ts string) (time.Time, error) {
+	seconds := ts
+	if dot := strings.IndexByte(ts, '.'); dot >= 0 {
+		seconds = ts[:dot]
+	}
+	unix, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(unix, 0), nil
+}
+
+func applyArchiveDecisions(ctx context.Context, client *slack.Client, decisions []archiveDecision) error {
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].channel.Name < decisions[j].channel.Name
+	})
+
+	fmt.Printf("\n--------------------\n")
+	fmt.Printf("%-30s %-14s %s\n", "CHANNEL", "ACTION", "REASON")
+	for _, d := range decisions {
+		var action string
+		switch {
+		case dryRun && d.archive:
+			action = "would archive"
+		case dryRun && !d.archive:
+			action = "would skip"
+		case d.archive:
+			action = "archived"
+		default:
+			action = "skipped"
+		}
+		fmt.Printf("%-30s %-14s %s\n", d.channel.Name, action, d.reason)
+	}
+
+	if dryRun {
+		fmt.Printf("\n--------------------\n")
+		fmt.Printf("Dry run only, no channels were archived.\n")
+		return nil
+	}
+
+	for _, d := range decisions {
+		if !d.archive {
+			continue
+		}
+		if err := client.ArchiveConversationContext(ctx, d.channel.ID); err != nil {
+			return fmt.Errorf("archiving %s: %s", d.channel.Name, err.Error())
+		}
+	}
+
+	return nil
+}
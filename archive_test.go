@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSlackTimestamp(t *testing.T) {
+	testCases := []struct {
+		ts      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"1583334395.000400", time.Unix(1583334395, 0), false},
+		{"1583334395", time.Unix(1583334395, 0), false},
+		{"not-a-timestamp", time.Time{}, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseSlackTimestamp(tc.ts)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseSlackTimestamp(%q): expected an error", tc.ts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseSlackTimestamp(%q): %s", tc.ts, err.Error())
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("parseSlackTimestamp(%q) = %v, want %v", tc.ts, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	// keyringService/keyringUser identify the OS keyring entry that holds
+	// the key used to encrypt the on-disk token cache.
+	keyringService = "roachslack"
+	keyringUser    = "token-cache-key"
+
+	// authStateTTL bounds how long an in-flight OAuth authorization is
+	// honored, so a stale or replayed callback is rejected.
+	authStateTTL = 5 * time.Minute
+)
+
+var (
+	oauthClientID     string
+	oauthClientSecret string
+)
+
+// authScopes are requested during 'auth login'; they mirror the
+// permissions joinSuppportCmd/leaveSuppportCmd/archiveStaleCmd need.
+var authScopes = []string{
+	"channels:read",
+	"channels:write",
+	"channels:history",
+	"groups:read",
+	"groups:write",
+}
+
+// cachedToken is what gets encrypted and written to token.json. RefreshToken
+// is only populated for Slack apps with token rotation enabled; without it,
+// an expired token can't be refreshed and the user has to log in again.
+type cachedToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "manage roachslack's cached Slack OAuth credentials\n",
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "authorize roachslack via the Slack OAuth 2.0 flow",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		tok, err := oauthLogin(context.Background())
+		if err != nil {
+			return err
+		}
+		if err := saveToken(tok); err != nil {
+			return err
+		}
+		fmt.Printf("Logged in. The access token has been cached for future commands.\n")
+		return nil
+	}),
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "purge the cached Slack access token",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		return purgeToken()
+	}),
+}
+
+func init() {
+	authCmd.AddCommand(authLoginCmd, authLogoutCmd)
+
+	authLoginCmd.Flags().StringVar(
+		&oauthClientID, "client-id", os.Getenv("SLACK_CLIENT_ID"),
+		"Slack app client ID",
+	)
+	authLoginCmd.Flags().StringVar(
+		&oauthClientSecret, "client-secret", os.Getenv("SLACK_CLIENT_SECRET"),
+		"Slack app client secret",
+	)
+}
+
+// oauthLogin drives the OAuth 2.0 authorization code flow end to end: it
+// opens a local callback listener, sends the user to Slack's authorize URL
+// with a CSRF state token, waits for the redirect, and exchanges the
+// resulting code for an access token.
+func oauthLogin(ctx context.Context) (*cachedToken, error) {
+	if len(oauthClientID) == 0 || len(oauthClientSecret) == 0 {
+		return nil, fmt.Errorf("--client-id/--client-secret (or $SLACK_CLIENT_ID/$SLACK_CLIENT_SECRET) are required")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+	issuedAt := time.Now()
+
+	authorizeURL := "https://slack.com/oauth/v2/authorize?" + url.Values{
+		"client_id":    {oauthClientID},
+		"scope":        {strings.Join(authScopes, ",")},
+		"redirect_uri": {redirectURI},
+		"state":        {state},
+	}.Encode()
+
+	fmt.Printf("\n--------------------\n")
+	fmt.Printf("Opening your browser to authorize roachslack:\n%s\n", authorizeURL)
+	openBrowser(authorizeURL)
+
+	type callbackResult struct {
+		code string
+		err  error
+	}
+	resultCh := make(chan callbackResult, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		if time.Since(issuedAt) > authStateTTL {
+			http.Error(w, "authorization timed out, please retry", http.StatusGone)
+			resultCh <- callbackResult{err: fmt.Errorf("authorization state expired")}
+			return
+		}
+		if r.URL.Query().Get("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			resultCh <- callbackResult{err: fmt.Errorf("state mismatch, possible CSRF attempt")}
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if len(code) == 0 {
+			http.Error(w, "missing code", http.StatusBadRequest)
+			resultCh <- callbackResult{err: fmt.Errorf("slack did not return an authorization code")}
+			return
+		}
+		fmt.Fprintf(w, "roachslack is now authorized, you can close this tab.")
+		resultCh <- callbackResult{code: code}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	select {
+	case res := <-resultCh:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return exchangeCode(ctx, res.code, redirectURI)
+	case <-time.After(authStateTTL):
+		return nil, fmt.Errorf("timed out waiting for the Slack OAuth callback")
+	}
+}
+
+func randomState() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func openBrowser(target string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Printf("Could not open a browser automatically, please visit the URL above.\n")
+	}
+}
+
+func exchangeCode(ctx context.Context, code, redirectURI string) (*cachedToken, error) {
+	return oauthAccessRequest(ctx, url.Values{
+		"client_id":     {oauthClientID},
+		"client_secret": {oauthClientSecret},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+	})
+}
+
+// refreshAccessToken exchanges a cached refresh token for a new access
+// token via Slack's token-rotation grant. It's only usable for Slack apps
+// that have token rotation enabled, which is why a missing refresh token
+// falls back to asking the user to log in again rather than calling this.
+func refreshAccessToken(ctx context.Context, refreshToken string) (*cachedToken, error) {
+	if len(oauthClientID) == 0 || len(oauthClientSecret) == 0 {
+		return nil, fmt.Errorf("--client-id/--client-secret (or $SLACK_CLIENT_ID/$SLACK_CLIENT_SECRET) are required to refresh a token")
+	}
+
+	tok, err := oauthAccessRequest(ctx, url.Values{
+		"client_id":     {oauthClientID},
+		"client_secret": {oauthClientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(tok.RefreshToken) == 0 {
+		// Slack doesn't always rotate the refresh token itself; keep the
+		// one we used if a new one wasn't issued.
+		tok.RefreshToken = refreshToken
+	}
+	return tok, nil
+}
+
+// oauthAccessRequest posts to oauth.v2.access and decodes the resulting
+// token, shared by both the authorization code exchange and the refresh
+// token grant.
+func oauthAccessRequest(ctx context.Context, form url.Values) (*cachedToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://slack.com/api/oauth.v2.access", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		OK           bool   `json:"ok"`
+		Error        string `json:"error"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if !body.OK {
+		return nil, fmt.Errorf("oauth.v2.access: %s", body.Error)
+	}
+
+	tok := &cachedToken{AccessToken: body.AccessToken, RefreshToken: body.RefreshToken}
+	if body.ExpiresIn > 0 {
+		tok.ExpiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+func tokenCachePath() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if len(base) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "roachslack", "token.json"), nil
+}
+
+// encryptionKey returns the AES key used to encrypt the token cache,
+// generating and storing a new one in the OS keyring the first time it's
+// needed.
+func encryptionKey() ([]byte, error) {
+	secret, err := keyring.Get(keyringService, keyringUser)
+	if err == keyring.ErrNotFound {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		secret = base64.StdEncoding.EncodeToString(key)
+		if err := keyring.Set(keyringService, keyringUser, secret); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(secret)
+}
+
+func saveToken(tok *cachedToken) error {
+	path, err := tokenCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+
+	key, err := encryptionKey()
+	if err != nil {
+		return err
+	}
+	ciphertext, err := encrypt(key, plaintext)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, ciphertext, 0600)
+}
+
+// loadCachedToken decrypts and returns the cached token, or an error if
+// none is cached.
+func loadCachedToken() (*cachedToken, error) {
+	path, err := tokenCachePath()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := decrypt(key, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	var tok cachedToken
+	if err := json.Unmarshal(plaintext, &tok); err != nil {
+		return nil, err
+	}
+	return &tok, nil
+}
+
+func purgeToken() error {
+	path, err := tokenCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := keyring.Delete(keyringService, keyringUser); err != nil && err != keyring.ErrNotFound {
+		return err
+	}
+	fmt.Printf("Removed the cached Slack credentials.\n")
+	return nil
+}
+
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("token cache is corrupt")
+	}
+	nonce, data := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	return gcm.Open(nil, nonce, data, nil)
+}
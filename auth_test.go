@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generating key: %s", err.Error())
+	}
+
+	plaintext := []byte(`{"access_token":"xoxb-test","expires_at":"2030-01-01T00:00:00Z"}`)
+
+	ciphertext, err := encrypt(key, plaintext)
+	if err != nil {
+		t.Fatalf("encrypt: %s", err.Error())
+	}
+	if bytes.Equal(ciphertext, plaintext) {
+		t.Fatalf("ciphertext must not equal plaintext")
+	}
+
+	got, err := decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatalf("decrypt: %s", err.Error())
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypt(encrypt(plaintext)) = %q, want %q", got, plaintext)
+	}
+}
+
+func TestDecryptWrongKeyFails(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generating key: %s", err.Error())
+	}
+	otherKey := make([]byte, 32)
+	if _, err := rand.Read(otherKey); err != nil {
+		t.Fatalf("generating key: %s", err.Error())
+	}
+
+	ciphertext, err := encrypt(key, []byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypt: %s", err.Error())
+	}
+
+	if _, err := decrypt(otherKey, ciphertext); err == nil {
+		t.Errorf("expected decrypting with the wrong key to fail")
+	}
+}
+
+func TestDecryptTruncatedCiphertext(t *testing.T) {
+	if _, err := decrypt(make([]byte, 32), []byte("short")); err == nil {
+		t.Errorf("expected decrypting a truncated ciphertext to fail")
+	}
+}
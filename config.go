@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var configPath string
+
+// Config describes the rules roachslack uses to decide which channels count
+// as support channels, and how to behave once one has been joined. It is
+// loaded from the file pointed to by --config or $ROACHSLACK_CONFIG; when
+// neither is set, defaultConfig is used instead.
+type Config struct {
+	// DefaultChannels is a literal list of channel names to always treat
+	// as support channels, in addition to anything matched by Include.
+	DefaultChannels []string `yaml:"default-channels"`
+
+	// Include is a list of glob patterns (or regexes, prefixed with
+	// "re:") that mark a channel as a support channel.
+	Include []string `yaml:"include"`
+
+	// Exclude is a list of glob or "re:" regex patterns that take priority
+	// over DefaultChannels and Include; a match here always wins, so it can
+	// be used to skip archived or sensitive rooms.
+	Exclude []string `yaml:"exclude"`
+
+	// ReadAfterJoin controls whether newly joined channels are
+	// immediately marked as read. Defaults to true, matching the
+	// existing unconditional behavior of joinSuppportCmd.
+	ReadAfterJoin *bool `yaml:"read-after-join"`
+}
+
+func defaultConfig() *Config {
+	readAfterJoin := true
+	return &Config{
+		DefaultChannels: defaultSupportChannels,
+		Include:         []string{"_*"},
+		ReadAfterJoin:   &readAfterJoin,
+	}
+}
+
+// loadConfig reads the config file at --config (falling back to
+// $ROACHSLACK_CONFIG), or returns defaultConfig if neither is set.
+func loadConfig() (*Config, error) {
+	path := configPath
+	if len(path) == 0 {
+		path = os.Getenv("ROACHSLACK_CONFIG")
+	}
+	if len(path) == 0 {
+		return defaultConfig(), nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err.Error())
+	}
+	return cfg, nil
+}
+
+// ChannelMatcher decides, from a resolved Config, whether a channel should
+// be treated as a support channel and whether it should be marked as read
+// after joining.
+type ChannelMatcher struct {
+	defaultNames  map[string]bool
+	include       []*regexp.Regexp
+	exclude       []*regexp.Regexp
+	readAfterJoin bool
+}
+
+// newChannelMatcher compiles cfg's patterns into a ChannelMatcher.
+func newChannelMatcher(cfg *Config) (*ChannelMatcher, error) {
+	m := &ChannelMatcher{
+		defaultNames:  make(map[string]bool),
+		readAfterJoin: true,
+	}
+	for _, name := range cfg.DefaultChannels {
+		m.defaultNames[name] = true
+	}
+	if cfg.ReadAfterJoin != nil {
+		m.readAfterJoin = *cfg.ReadAfterJoin
+	}
+
+	var err error
+	if m.include, err = compilePatterns(cfg.Include); err != nil {
+		return nil, err
+	}
+	if m.exclude, err = compilePatterns(cfg.Exclude); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// IsSupportChannel reports whether name should be treated as a support
+// channel under m's rules. Exclude patterns take priority over everything
+// else.
+func (m *ChannelMatcher) IsSupportChannel(name string) bool {
+	for _, re := range m.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if m.defaultNames[name] {
+		return true
+	}
+	for _, re := range m.include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExcluded reports whether name matches one of m's exclude patterns.
+func (m *ChannelMatcher) IsExcluded(name string) bool {
+	for _, re := range m.exclude {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+const regexPrefix = "re:"
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		var re *regexp.Regexp
+		var err error
+		if strings.HasPrefix(pattern, regexPrefix) {
+			re, err = regexp.Compile(strings.TrimPrefix(pattern, regexPrefix))
+		} else {
+			re, err = regexp.Compile(globToRegexp(pattern))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", pattern, err.Error())
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// globToRegexp translates a shell-style glob ("*" and "?") into an anchored
+// regexp source string.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "inspect the effective roachslack configuration\n",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "print the effective resolved ruleset",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		out, err := yaml.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s", out)
+		return nil
+	}),
+}
+
+func init() {
+	configCmd.AddCommand(configPrintCmd)
+}
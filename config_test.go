@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	testCases := []struct {
+		glob  string
+		match string
+		want  bool
+	}{
+		{"_*", "_acme-support", true},
+		{"_*", "acme-support", false},
+		{"_acme-?", "_acme-1", true},
+		{"_acme-?", "_acme-12", false},
+		{"general", "general", true},
+		{"general", "general2", false},
+	}
+
+	for _, tc := range testCases {
+		re, err := compilePatterns([]string{tc.glob})
+		if err != nil {
+			t.Fatalf("compilePatterns(%q): %s", tc.glob, err.Error())
+		}
+		if got := re[0].MatchString(tc.match); got != tc.want {
+			t.Errorf("globToRegexp(%q) matching %q = %v, want %v", tc.glob, tc.match, got, tc.want)
+		}
+	}
+}
+
+func TestCompilePatternsRegexPrefix(t *testing.T) {
+	re, err := compilePatterns([]string{"re:^_(acme|initech)-.*$"})
+	if err != nil {
+		t.Fatalf("compilePatterns: %s", err.Error())
+	}
+	if !re[0].MatchString("_acme-billing") {
+		t.Errorf("expected _acme-billing to match")
+	}
+	if re[0].MatchString("_other-billing") {
+		t.Errorf("expected _other-billing not to match")
+	}
+}
+
+func TestCompilePatternsInvalid(t *testing.T) {
+	if _, err := compilePatterns([]string{"re:("}); err == nil {
+		t.Errorf("expected an error for an invalid regex pattern")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/spf13/cobra"
+	bolt "go.etcd.io/bbolt"
+)
+
+// watchReconnectBackoff* bound how quickly the daemon retries after the RTM
+// event stream closes, so a sustained outage or bad credential doesn't turn
+// into a busy loop against Slack.
+const (
+	watchReconnectBackoffMin = 1 * time.Second
+	watchReconnectBackoffMax = 2 * time.Minute
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch --key=\"xxx\"",
+	Short: "watch the workspace and auto-join new support channels as they appear",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		client, user, err := checkAuth(ctx)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		matcher, err := newChannelMatcher(cfg)
+		if err != nil {
+			return err
+		}
+
+		db, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		return runWatch(ctx, client, matcher, db, user)
+	}),
+}
+
+// runWatch opens an RTM connection and reacts to channel_created and
+// channel_rename events, joining (and recording to the history store, and,
+// when read-after-join is on, marking as read) any channel the
+// ChannelMatcher considers a support channel, using the same logic as
+// joinSuppportCmd. It runs until ctx is cancelled or Slack reports invalid
+// credentials, reconnecting the RTM session whenever the event stream closes.
+func runWatch(ctx context.Context, client *slack.Client, matcher *ChannelMatcher, db *bolt.DB, user string) error {
+	rtm := client.NewRTM()
+	go rtm.ManageConnection()
+	defer func() { rtm.Disconnect() }()
+
+	fmt.Printf("\n--------------------\n")
+	fmt.Printf("Watching for new support channels. Press Ctrl-C to stop.\n")
+
+	backoff := watchReconnectBackoffMin
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case msg, ok := <-rtm.IncomingEvents:
+			if !ok {
+				// The RTM library closes IncomingEvents when it gives up
+				// reconnecting internally; start a fresh session rather
+				// than exit the daemon, backing off so a sustained outage
+				// doesn't busy-loop reconnect attempts against Slack.
+				fmt.Printf("RTM connection lost, reconnecting in %s.\n", backoff)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(backoff):
+				}
+				if backoff *= 2; backoff > watchReconnectBackoffMax {
+					backoff = watchReconnectBackoffMax
+				}
+				rtm = client.NewRTM()
+				go rtm.ManageConnection()
+				continue
+			}
+
+			switch ev := msg.Data.(type) {
+			case *slack.ConnectedEvent:
+				fmt.Printf("Connected to %s as %s\n", ev.Info.Team.Name, ev.Info.User.Name)
+				backoff = watchReconnectBackoffMin
+
+			case *slack.LatencyReport:
+				// Keepalive ping/pong; nothing to do.
+
+			case *slack.ChannelCreatedEvent:
+				if err := maybeJoinChannel(ctx, client, matcher, db, user, ev.Channel.Name, ev.Channel.ID); err != nil {
+					fmt.Printf("Error joining %s: %s\n", ev.Channel.Name, err.Error())
+				}
+
+			case *slack.ChannelRenameEvent:
+				if err := maybeJoinChannel(ctx, client, matcher, db, user, ev.Channel.Name, ev.Channel.ID); err != nil {
+					fmt.Printf("Error joining %s: %s\n", ev.Channel.Name, err.Error())
+				}
+
+			case *slack.RTMError:
+				fmt.Printf("RTM error: %s\n", ev.Error())
+
+			case *slack.InvalidAuthEvent:
+				return fmt.Errorf("invalid slack auth credentials")
+			}
+		}
+	}
+}
+
+// maybeJoinChannel joins and records to the history store a newly seen or
+// renamed channel, provided the ChannelMatcher considers it a support
+// channel, and marks it as read when read-after-join is on. It respects --dry the same way joinSuppportCmd does.
+func maybeJoinChannel(
+	ctx context.Context, client *slack.Client, matcher *ChannelMatcher, db *bolt.DB, user, name, id string,
+) error {
+	if !matcher.IsSupportChannel(name) {
+		return nil
+	}
+
+	fmt.Printf("\n--------------------\n")
+	fmt.Printf("New support channel detected: %s\n", name)
+
+	if dryRun {
+		fmt.Printf("Dry run only, %s was not joined.\n", name)
+		return nil
+	}
+
+	info, err := client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: id})
+	if err != nil {
+		return fmt.Errorf("looking up %s: %s", name, err.Error())
+	}
+	extShared := info.IsExtShared
+
+	now := time.Now()
+	opID := newOpID(opJoin, id, now)
+	rec := opRecord{
+		ID:          opID,
+		Kind:        opJoin,
+		ChannelID:   id,
+		ChannelName: name,
+		ExtShared:   extShared,
+		User:        user,
+		At:          now,
+	}
+	if err := recordOps(db, []opRecord{rec}); err != nil {
+		return err
+	}
+
+	if _, err := client.JoinChannelContext(ctx, name); err != nil {
+		return err
+	}
+	fmt.Printf("Joined %s\n", name)
+	if err := updateOp(db, opID, func(r *opRecord) { r.Done = true }); err != nil {
+		return err
+	}
+
+	if matcher.readAfterJoin {
+		timestamp := fmt.Sprintf("%d", time.Now().Unix())
+		if err := client.SetChannelReadMarkContext(ctx, id, timestamp); err != nil {
+			return err
+		}
+		fmt.Printf("%s is marked as read.\n", name)
+	}
+
+	return nil
+}
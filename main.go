@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/nlopes/slack"
@@ -42,21 +41,42 @@ func wrap(f func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Comma
 	}
 }
 
-func checkAuth(ctx context.Context) (*slack.Client, error) {
-	if len(slackKey) == 0 {
-		return nil, fmt.Errorf("no slack auth key provided")
+func checkAuth(ctx context.Context) (*slack.Client, string, error) {
+	key := slackKey
+	if len(key) == 0 {
+		tok, err := loadCachedToken()
+		if err != nil {
+			return nil, "", fmt.Errorf("no slack auth key provided; use --key or run 'roachslack auth login'")
+		}
+		if !tok.ExpiresAt.IsZero() && time.Now().After(tok.ExpiresAt) {
+			if len(tok.RefreshToken) == 0 {
+				return nil, "", fmt.Errorf("cached Slack token has expired, run 'roachslack auth login' again")
+			}
+			refreshed, err := refreshAccessToken(ctx, tok.RefreshToken)
+			if err != nil {
+				return nil, "", fmt.Errorf("cached Slack token has expired and refreshing it failed (%s), run 'roachslack auth login' again", err.Error())
+			}
+			// Use the refreshed token for this run even if caching it
+			// fails, so a transient local I/O error doesn't waste an
+			// already-consumed (and possibly rotated) refresh token.
+			if err := saveToken(refreshed); err != nil {
+				fmt.Printf("Warning: could not cache the refreshed Slack token: %s\n", err.Error())
+			}
+			tok = refreshed
+		}
+		key = tok.AccessToken
 	}
 
-	api := slack.New(slackKey)
+	api := slack.New(key)
 
 	// Check that the user is signed in with a real token.
 	authResp, err := api.AuthTestContext(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	fmt.Printf("Logged in as: %s\n\tTeam: %s\n", authResp.User, authResp.Team)
-	return api, nil
+	return api, authResp.User, nil
 }
 
 func getAllChannels(ctx context.Context, client *slack.Client) ([]slack.Channel, error) {
@@ -87,7 +107,7 @@ var joinSuppportCmd = &cobra.Command{
 	Run: wrap(func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
-		client, err := checkAuth(ctx)
+		client, user, err := checkAuth(ctx)
 		if err != nil {
 			return err
 		}
@@ -97,28 +117,28 @@ var joinSuppportCmd = &cobra.Command{
 			return err
 		}
 
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		matcher, err := newChannelMatcher(cfg)
+		if err != nil {
+			return err
+		}
+
 		var supportChannelNames []string
 		channelIDs := make(map[string]string)
+		channelExtShared := make(map[string]bool)
 		for _, channel := range channels {
 			// Don't join channels you already belong to.
 			if channel.IsMember {
 				continue
 			}
 
-			// Add all customer channels
-			if strings.HasPrefix(channel.Name, "_") {
+			if matcher.IsSupportChannel(channel.Name) {
 				supportChannelNames = append(supportChannelNames, channel.Name)
 				channelIDs[channel.Name] = channel.ID
-				continue
-			}
-
-			// Add default support channels.
-			for _, defaultChannel := range defaultSupportChannels {
-				if defaultChannel == channel.Name {
-					supportChannelNames = append(supportChannelNames, channel.Name)
-					channelIDs[channel.Name] = channel.ID
-					continue
-				}
+				channelExtShared[channel.Name] = channel.IsExtShared
 			}
 		}
 
@@ -141,26 +161,61 @@ var joinSuppportCmd = &cobra.Command{
 			return nil
 		}
 
+		db, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		// Record the planned joins before performing any of them, so a
+		// crash mid-batch leaves a resumable record that 'roachslack
+		// resume' can finish.
+		now := time.Now()
+		opIDs := make(map[string]string, len(supportChannelNames))
+		var records []opRecord
+		for _, channelName := range supportChannelNames {
+			id := newOpID(opJoin, channelIDs[channelName], now)
+			opIDs[channelName] = id
+			records = append(records, opRecord{
+				ID:          id,
+				Kind:        opJoin,
+				ChannelID:   channelIDs[channelName],
+				ChannelName: channelName,
+				ExtShared:   channelExtShared[channelName],
+				User:        user,
+				At:          now,
+			})
+		}
+		if err := recordOps(db, records); err != nil {
+			return err
+		}
+
 		// Join the channels.
 		for _, channelName := range supportChannelNames {
 			if _, err := client.JoinChannelContext(ctx, channelName); err != nil {
 				return err
 			}
 			fmt.Printf("Joined %s\n", channelName)
+			if err := updateOp(db, opIDs[channelName], func(r *opRecord) { r.Done = true }); err != nil {
+				return err
+			}
 		}
 
-		// Marking the joined channels as read.
-		fmt.Printf("\n--------------------\n")
-		fmt.Printf("Marking all the joined channels as read.\n")
-		time.Sleep(5 * time.Second)
-		timestamp := fmt.Sprintf("%d", time.Now().Unix())
-		fmt.Printf("%s\n", timestamp)
-		for _, channelName := range supportChannelNames {
-			channelID := channelIDs[channelName]
-			if err := client.SetChannelReadMarkContext(ctx, channelID, timestamp); err != nil {
-				return err
+		// Marking the joined channels as read, unless the config has
+		// opted out via read-after-join: false.
+		if matcher.readAfterJoin {
+			fmt.Printf("\n--------------------\n")
+			fmt.Printf("Marking all the joined channels as read.\n")
+			time.Sleep(5 * time.Second)
+			timestamp := fmt.Sprintf("%d", time.Now().Unix())
+			fmt.Printf("%s\n", timestamp)
+			for _, channelName := range supportChannelNames {
+				channelID := channelIDs[channelName]
+				if err := client.SetChannelReadMarkContext(ctx, channelID, timestamp); err != nil {
+					return err
+				}
+				fmt.Printf("%s is marked as read.\n", channelName)
 			}
-			fmt.Printf("%s is marked as read.\n", channelName)
 		}
 
 		fmt.Printf("\n--------------------\n")
@@ -177,7 +232,7 @@ var leaveSuppportCmd = &cobra.Command{
 	Run: wrap(func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
-		client, err := checkAuth(ctx)
+		client, user, err := checkAuth(ctx)
 		if err != nil {
 			return err
 		}
@@ -187,6 +242,15 @@ var leaveSuppportCmd = &cobra.Command{
 			return err
 		}
 
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		matcher, err := newChannelMatcher(cfg)
+		if err != nil {
+			return err
+		}
+
 		var supportChannelNames []string
 		var cannotLeave []string
 		channelIDs := make(map[string]string)
@@ -196,17 +260,19 @@ var leaveSuppportCmd = &cobra.Command{
 				continue
 			}
 
-			// Add all customer channels
-			if strings.HasPrefix(channel.Name, "_") {
-				// Sadly, externally shared channels cannot be left via the API as far as I can tell.
-				if channel.IsExtShared {
-					cannotLeave = append(cannotLeave, channel.Name)
-					continue
-				}
-				supportChannelNames = append(supportChannelNames, channel.Name)
-				channelIDs[channel.Name] = channel.ID
+			// Leave the main support channels (the config's default
+			// channels) alone; only client support channels are left.
+			if !matcher.IsSupportChannel(channel.Name) || matcher.defaultNames[channel.Name] {
 				continue
 			}
+
+			// Sadly, externally shared channels cannot be left via the API as far as I can tell.
+			if channel.IsExtShared {
+				cannotLeave = append(cannotLeave, channel.Name)
+				continue
+			}
+			supportChannelNames = append(supportChannelNames, channel.Name)
+			channelIDs[channel.Name] = channel.ID
 		}
 
 		fmt.Printf("\n--------------------\n")
@@ -232,6 +298,34 @@ var leaveSuppportCmd = &cobra.Command{
 			return nil
 		}
 
+		db, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		// Record the planned leaves before performing any of them, so a
+		// crash mid-batch leaves a resumable record that 'roachslack
+		// resume' can finish.
+		now := time.Now()
+		opIDs := make(map[string]string, len(supportChannelNames))
+		var records []opRecord
+		for _, channelName := range supportChannelNames {
+			id := newOpID(opLeave, channelIDs[channelName], now)
+			opIDs[channelName] = id
+			records = append(records, opRecord{
+				ID:          id,
+				Kind:        opLeave,
+				ChannelID:   channelIDs[channelName],
+				ChannelName: channelName,
+				User:        user,
+				At:          now,
+			})
+		}
+		if err := recordOps(db, records); err != nil {
+			return err
+		}
+
 		fmt.Printf("\n--------------------\n")
 		for _, channelName := range supportChannelNames {
 			channelID := channelIDs[channelName]
@@ -239,6 +333,9 @@ var leaveSuppportCmd = &cobra.Command{
 				return err
 			}
 			fmt.Printf("Left %s\n", channelName)
+			if err := updateOp(db, opIDs[channelName], func(r *opRecord) { r.Done = true }); err != nil {
+				return err
+			}
 		}
 
 		fmt.Printf("\n--------------------\n")
@@ -263,11 +360,20 @@ func main() {
 	rootCmd.AddCommand(
 		joinSuppportCmd,
 		leaveSuppportCmd,
+		watchCmd,
+		configCmd,
+		archiveStaleCmd,
+		authCmd,
+		notifyCmd,
+		historyCmd,
+		undoCmd,
+		resumeCmd,
 	)
 
 	rootCmd.PersistentFlags().StringVarP(
 		&slackKey, "key", "k", os.Getenv("SLACK_KEY"),
-		"Slack API Key: See https://api.slack.com/custom-integrations/legacy-tokens",
+		"Slack API Key (legacy token, deprecated): See https://api.slack.com/custom-integrations/legacy-tokens. "+
+			"Prefer 'roachslack auth login', which is used automatically when --key is absent.",
 	)
 
 	rootCmd.PersistentFlags().BoolVarP(
@@ -275,6 +381,59 @@ func main() {
 		"Perform a dry run only, don't change any settings",
 	)
 
+	rootCmd.PersistentFlags().StringVarP(
+		&configPath, "config", "c", "",
+		"Path to a roachslack config file (see 'roachslack config print'); falls back to $ROACHSLACK_CONFIG",
+	)
+
+	archiveStaleCmd.Flags().DurationVar(
+		&staleThreshold, "stale", 720*time.Hour,
+		"Archive _ channels whose last message is older than this",
+	)
+
+	notifyCmd.Flags().StringVar(
+		&notifyTemplate, "template", "",
+		"Name of a text/template file under $XDG_CONFIG_HOME/roachslack/templates/",
+	)
+	notifyCmd.Flags().StringArrayVar(
+		&notifyVars, "vars", nil,
+		"key=value pairs made available to the template, may be repeated",
+	)
+	notifyCmd.Flags().StringVar(
+		&notifySeverity, "severity", "info",
+		"Attachment color: good, warning, or critical (accepts a raw hex color too)",
+	)
+	notifyCmd.Flags().StringVar(
+		&notifyRate, "rate", "1/s",
+		"Maximum post rate, e.g. \"1/s\" or \"2/m\"",
+	)
+	notifyCmd.Flags().BoolVar(
+		&notifyThreadBroadcast, "thread-broadcast", false,
+		"Also cross-post a summary to --status-channel",
+	)
+	notifyCmd.Flags().StringVar(
+		&notifyStatusChannel, "status-channel", "",
+		"Channel to cross-post a summary to when --thread-broadcast is set",
+	)
+	notifyCmd.Flags().StringVar(
+		&notifyTitle, "title", "",
+		"Attachment title shown above the rendered template body",
+	)
+	notifyCmd.Flags().StringVar(
+		&notifyTitleLink, "title-link", "",
+		"URL the attachment title links to, e.g. an incident doc",
+	)
+
+	historyCmd.Flags().DurationVar(
+		&historySince, "since", 7*24*time.Hour,
+		"Show operations recorded within this long of now",
+	)
+
+	undoCmd.Flags().DurationVar(
+		&undoSince, "since", 24*time.Hour,
+		"Reverse operations recorded within this long of now",
+	)
+
 	if err := rootCmd.Execute(); err != nil {
 		// Cobra has already printed the error message.
 		os.Exit(1)
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/spf13/cobra"
+)
+
+var (
+	notifyTemplate        string
+	notifyVars            []string
+	notifyRate            string
+	notifySeverity        string
+	notifyThreadBroadcast bool
+	notifyStatusChannel   string
+	notifyTitle           string
+	notifyTitleLink       string
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify --template=\"incident.tmpl\" --vars key=value",
+	Short: "broadcast a templated message across joined support channels",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		if notifyThreadBroadcast && len(notifyStatusChannel) == 0 {
+			return fmt.Errorf("--thread-broadcast requires --status-channel")
+		}
+
+		ctx := context.Background()
+
+		client, _, err := checkAuth(ctx)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		matcher, err := newChannelMatcher(cfg)
+		if err != nil {
+			return err
+		}
+
+		body, err := renderTemplate(notifyTemplate, notifyVars)
+		if err != nil {
+			return err
+		}
+
+		interval, err := parseRate(notifyRate)
+		if err != nil {
+			return err
+		}
+
+		channels, err := getAllChannels(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		var targets []slack.Channel
+		for _, channel := range channels {
+			if !channel.IsMember || !matcher.IsSupportChannel(channel.Name) {
+				continue
+			}
+			targets = append(targets, channel)
+		}
+
+		fmt.Printf("\n--------------------\n")
+
+		if len(targets) == 0 {
+			fmt.Printf("There are no joined support channels to notify.\n")
+			return nil
+		}
+
+		if dryRun {
+			fmt.Printf("Dry run only, the following message would be posted to %d channels:\n\n%s\n\n", len(targets), body)
+			for _, channel := range targets {
+				fmt.Printf("%s\n", channel.Name)
+			}
+			return nil
+		}
+
+		attachment := slack.Attachment{
+			Color:      severityColor(notifySeverity),
+			Title:      notifyTitle,
+			TitleLink:  notifyTitleLink,
+			Text:       body,
+			MarkdownIn: []string{"text"},
+		}
+
+		fmt.Printf("Posting to %d channels:\n", len(targets))
+		for _, channel := range targets {
+			if _, _, err := client.PostMessageContext(ctx, channel.ID, slack.MsgOptionAttachments(attachment)); err != nil {
+				return fmt.Errorf("posting to %s: %s", channel.Name, err.Error())
+			}
+			fmt.Printf("Posted to %s\n", channel.Name)
+			if interval > 0 {
+				time.Sleep(interval)
+			}
+		}
+
+		if notifyThreadBroadcast {
+			summary := fmt.Sprintf("Broadcast sent to %d support channels.", len(targets))
+			if _, _, err := client.PostMessageContext(ctx, notifyStatusChannel, slack.MsgOptionText(summary, false)); err != nil {
+				return fmt.Errorf("cross-posting to %s: %s", notifyStatusChannel, err.Error())
+			}
+			fmt.Printf("Cross-posted a summary to %s\n", notifyStatusChannel)
+		}
+
+		fmt.Printf("\n--------------------\n")
+		fmt.Printf("Done!\n\n")
+
+		return nil
+	}),
+}
+
+// renderTemplate loads the named template from
+// $XDG_CONFIG_HOME/roachslack/templates/ and executes it against the
+// key=value pairs supplied via --vars.
+func renderTemplate(name string, vars []string) (string, error) {
+	path, err := templatePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("parsing template %s: %s", path, err.Error())
+	}
+
+	values := make(map[string]string, len(vars))
+	for _, kv := range vars {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return "", fmt.Errorf("invalid --vars entry %q, expected key=value", kv)
+		}
+		values[parts[0]] = parts[1]
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return "", fmt.Errorf("executing template %s: %s", path, err.Error())
+	}
+	return buf.String(), nil
+}
+
+func templatePath(name string) (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if len(base) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "roachslack", "templates", name), nil
+}
+
+// parseRate parses a throttle rate like "1/s" or "2/m" into the delay
+// between successive posts.
+func parseRate(rate string) (time.Duration, error) {
+	if len(rate) == 0 {
+		return 0, nil
+	}
+
+	parts := strings.SplitN(rate, "/", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid --rate %q, expected a format like \"1/s\"", rate)
+	}
+
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --rate %q, expected a format like \"1/s\"", rate)
+	}
+
+	var unit time.Duration
+	switch parts[1] {
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	default:
+		return 0, fmt.Errorf("invalid --rate unit %q, expected s, m, or h", parts[1])
+	}
+
+	return unit / time.Duration(n), nil
+}
+
+func severityColor(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "incident":
+		return "danger"
+	case "warning":
+		return "warning"
+	case "good", "info":
+		return "good"
+	default:
+		return severity
+	}
+}
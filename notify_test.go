@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	testCases := []struct {
+		rate    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1/s", time.Second, false},
+		{"2/s", 500 * time.Millisecond, false},
+		{"1/m", time.Minute, false},
+		{"1/h", time.Hour, false},
+		{"bogus", 0, true},
+		{"0/s", 0, true},
+		{"1/d", 0, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseRate(tc.rate)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseRate(%q): expected an error", tc.rate)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseRate(%q): %s", tc.rate, err.Error())
+		}
+		if got != tc.want {
+			t.Errorf("parseRate(%q) = %v, want %v", tc.rate, got, tc.want)
+		}
+	}
+}
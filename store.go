@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/spf13/cobra"
+	bolt "go.etcd.io/bbolt"
+)
+
+// opsBucket holds one opRecord per join/leave roachslack has ever performed
+// (or attempted), keyed by opRecord.ID.
+const opsBucket = "ops"
+
+type opKind string
+
+const (
+	opJoin  opKind = "join"
+	opLeave opKind = "leave"
+)
+
+// opRecord is a single join or leave, recorded before it is attempted so
+// that a crash mid-batch leaves a resumable trail: 'roachslack resume'
+// finishes anything left with Done == false, and 'roachslack undo' reverses
+// anything with Done == true && Undone == false.
+type opRecord struct {
+	ID          string    `json:"id"`
+	Kind        opKind    `json:"kind"`
+	ChannelID   string    `json:"channel_id"`
+	ChannelName string    `json:"channel_name"`
+	ExtShared   bool      `json:"ext_shared"`
+	User        string    `json:"user"`
+	At          time.Time `json:"at"`
+	Done        bool      `json:"done"`
+	Undone      bool      `json:"undone"`
+}
+
+func newOpID(kind opKind, channelID string, at time.Time) string {
+	return fmt.Sprintf("%d-%s-%s", at.UnixNano(), kind, channelID)
+}
+
+func statePath() (string, error) {
+	base := os.Getenv("XDG_STATE_HOME")
+	if len(base) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(base, "roachslack", "state.db"), nil
+}
+
+// openStore opens (and, the first time, creates) the local bbolt store used
+// to track join/leave history.
+func openStore() (*bolt.DB, error) {
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(opsBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// recordOps writes a batch of operations in a single transaction, so either
+// the whole batch is durable or none of it is.
+func recordOps(db *bolt.DB, records []opRecord) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(opsBucket))
+		for _, rec := range records {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(rec.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func updateOp(db *bolt.DB, id string, mutate func(rec *opRecord)) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(opsBucket))
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("no such operation %s", id)
+		}
+		var rec opRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		mutate(&rec)
+		updated, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), updated)
+	})
+}
+
+// listOps returns every recorded operation with At after since, oldest
+// first.
+func listOps(db *bolt.DB, since time.Time) ([]opRecord, error) {
+	var records []opRecord
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(opsBucket))
+		return bucket.ForEach(func(k, v []byte) error {
+			var rec opRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if rec.At.After(since) {
+				records = append(records, rec)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].At.Before(records[j].At) })
+	return records, nil
+}
+
+var historySince time.Duration
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "list recent join/leave operations",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		db, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		records, err := listOps(db, time.Now().Add(-historySince))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n--------------------\n")
+		if len(records) == 0 {
+			fmt.Printf("No recorded operations in the last %s.\n", historySince)
+			return nil
+		}
+
+		for _, rec := range records {
+			status := "done"
+			switch {
+			case rec.Undone:
+				status = "undone"
+			case !rec.Done:
+				status = "pending"
+			}
+			fmt.Printf("%s  %-5s  %-30s  %-7s  %s\n",
+				rec.At.Format(time.RFC3339), rec.Kind, rec.ChannelName, status, rec.User)
+		}
+		return nil
+	}),
+}
+
+var undoSince time.Duration
+
+var undoCmd = &cobra.Command{
+	Use:   "undo --since=24h",
+	Short: "reverse recent join/leave operations",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		client, _, err := checkAuth(ctx)
+		if err != nil {
+			return err
+		}
+
+		db, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		records, err := listOps(db, time.Now().Add(-undoSince))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n--------------------\n")
+
+		var cannotUndo []string
+		for _, rec := range records {
+			if !rec.Done || rec.Undone {
+				continue
+			}
+
+			if rec.ExtShared {
+				cannotUndo = append(cannotUndo, fmt.Sprintf("%s: externally shared, must be left manually", rec.ChannelName))
+				continue
+			}
+
+			switch rec.Kind {
+			case opJoin:
+				fmt.Printf("Leaving %s (undoing a join)\n", rec.ChannelName)
+			case opLeave:
+				fmt.Printf("Joining %s (undoing a leave)\n", rec.ChannelName)
+			}
+
+			if dryRun {
+				continue
+			}
+
+			if err := reverseOp(ctx, client, rec); err != nil {
+				cannotUndo = append(cannotUndo, fmt.Sprintf("%s: %s", rec.ChannelName, err.Error()))
+				continue
+			}
+
+			if err := updateOp(db, rec.ID, func(r *opRecord) { r.Undone = true }); err != nil {
+				return err
+			}
+		}
+
+		if len(cannotUndo) > 0 {
+			fmt.Printf("\n--------------------\n")
+			fmt.Printf("The following operations could not be undone:\n")
+			for _, msg := range cannotUndo {
+				fmt.Printf("%s\n", msg)
+			}
+		}
+
+		if dryRun {
+			fmt.Printf("\n--------------------\n")
+			fmt.Printf("Dry run only, no operations were undone.\n")
+		}
+
+		return nil
+	}),
+}
+
+// reverseOp performs the opposite Slack action of rec: leaving a channel
+// that was joined, or re-joining one that was left.
+func reverseOp(ctx context.Context, client *slack.Client, rec opRecord) error {
+	switch rec.Kind {
+	case opJoin:
+		_, err := client.LeaveChannelContext(ctx, rec.ChannelID)
+		return err
+	case opLeave:
+		_, err := client.JoinChannelContext(ctx, rec.ChannelName)
+		return err
+	default:
+		return fmt.Errorf("unknown operation kind %q", rec.Kind)
+	}
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "finish any join/leave batch left incomplete by a crash",
+	Args:  cobra.NoArgs,
+	Run: wrap(func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		client, _, err := checkAuth(ctx)
+		if err != nil {
+			return err
+		}
+
+		db, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		records, err := listOps(db, time.Time{})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n--------------------\n")
+
+		var pending int
+		for _, rec := range records {
+			if rec.Done {
+				continue
+			}
+			pending++
+
+			fmt.Printf("Resuming %s on %s\n", rec.Kind, rec.ChannelName)
+			if dryRun {
+				continue
+			}
+
+			if err := performOp(ctx, client, rec); err != nil {
+				return fmt.Errorf("resuming %s on %s: %s", rec.Kind, rec.ChannelName, err.Error())
+			}
+			if err := updateOp(db, rec.ID, func(r *opRecord) { r.Done = true }); err != nil {
+				return err
+			}
+		}
+
+		if pending == 0 {
+			fmt.Printf("Nothing to resume.\n")
+		}
+
+		return nil
+	}),
+}
+
+// performOp performs the Slack action described by rec, without recording
+// anything new; the caller is expected to already hold the pending record.
+func performOp(ctx context.Context, client *slack.Client, rec opRecord) error {
+	switch rec.Kind {
+	case opJoin:
+		_, err := client.JoinChannelContext(ctx, rec.ChannelName)
+		return err
+	case opLeave:
+		_, err := client.LeaveChannelContext(ctx, rec.ChannelID)
+		return err
+	default:
+		return fmt.Errorf("unknown operation kind %q", rec.Kind)
+	}
+}